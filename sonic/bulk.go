@@ -0,0 +1,339 @@
+package sonic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultWorkerPoolSize = 4
+	defaultPipelineDepth  = 1
+	defaultRetryMax       = 2
+	defaultRetryBase      = 50 * time.Millisecond
+)
+
+// bulkConfig controls how BulkPush/BulkPop dispatch and retry work.
+type bulkConfig struct {
+	workerPoolSize int
+	pipelineDepth  int
+	retryMax       int
+	retryBase      time.Duration
+	errCh          chan<- IngestBulkError
+}
+
+func defaultBulkConfig() bulkConfig {
+	return bulkConfig{
+		workerPoolSize: defaultWorkerPoolSize,
+		pipelineDepth:  defaultPipelineDepth,
+		retryMax:       defaultRetryMax,
+		retryBase:      defaultRetryBase,
+	}
+}
+
+// IngesterOption configures the bulk dispatch behaviour of an Ingestable
+// returned by NewIngester.
+type IngesterOption func(*bulkConfig)
+
+// WithWorkerPoolSize sets how many persistent connections (and goroutines)
+// BulkPush/BulkPop use by default. Overridden per-call by a positive
+// parallelRoutines argument. Defaults to 4.
+func WithWorkerPoolSize(n int) IngesterOption {
+	return func(c *bulkConfig) { c.workerPoolSize = n }
+}
+
+// WithPipelineDepth sets how many commands a bulk worker writes on its
+// connection before blocking to read back the matching replies. Defaults to 1
+// (no pipelining).
+func WithPipelineDepth(k int) IngesterOption {
+	return func(c *bulkConfig) { c.pipelineDepth = k }
+}
+
+// WithRetry sets the retry policy applied to transient errors (io.EOF,
+// ErrClosed, read/write timeouts) encountered during a bulk operation: up to
+// max retries after the first attempt, with exponential backoff starting at
+// base. Defaults to 2
+// retries with a 50ms base.
+func WithRetry(max int, base time.Duration) IngesterOption {
+	return func(c *bulkConfig) { c.retryMax = max; c.retryBase = base }
+}
+
+// WithBulkErrorChannel streams every IngestBulkError onto ch, in addition to
+// returning them from BulkPush/BulkPop as usual. ch is written to from
+// worker goroutines and must either be buffered or actively drained by the
+// caller, or bulk operations will stall.
+func WithBulkErrorChannel(ch chan<- IngestBulkError) IngesterOption {
+	return func(c *bulkConfig) { c.errCh = ch }
+}
+
+// connPool is a small pool of persistent driver connections reused across
+// bulk operations, so BulkPush/BulkPop don't open a fresh TCP connection per
+// call.
+type connPool struct {
+	seed *driver
+	pool chan *driver
+}
+
+func newConnPool(seed *driver, size int) *connPool {
+	if size <= 0 {
+		size = 1
+	}
+	return &connPool{seed: seed, pool: make(chan *driver, size)}
+}
+
+// get returns an idle pooled connection, or opens a new one if the pool is
+// empty.
+func (p *connPool) get() (*driver, error) {
+	select {
+	case conn := <-p.pool:
+		return conn, nil
+	default:
+		return newConnection(p.seed)
+	}
+}
+
+// put returns conn to the pool, or closes it if the pool is full. A nil conn
+// is ignored; put does not check whether conn is still usable.
+func (p *connPool) put(conn *driver) {
+	if conn == nil {
+		return
+	}
+	select {
+	case p.pool <- conn:
+	default:
+		_ = conn.close()
+	}
+}
+
+// closeAll closes every connection currently idle in the pool. Connections
+// checked out by a running worker aren't affected; once that worker finishes
+// they're either closed or put back into the pool, where they stay open.
+func (p *connPool) closeAll() {
+	for {
+		select {
+		case conn := <-p.pool:
+			_ = conn.close()
+		default:
+			return
+		}
+	}
+}
+
+// chunkProgress tracks how far a single bulk worker has gotten through its
+// share of records, so Drain can report the unattempted remainder if it
+// times out while the worker is still running.
+type chunkProgress struct {
+	recs   []IngestBulkRecord
+	cursor int32 // atomic; index of the next record not yet attempted
+}
+
+func (c *chunkProgress) remaining() []IngestBulkRecord {
+	idx := atomic.LoadInt32(&c.cursor)
+	if int(idx) >= len(c.recs) {
+		return nil
+	}
+	return c.recs[idx:]
+}
+
+func (c *chunkProgress) advance(n int) {
+	atomic.AddInt32(&c.cursor, int32(n))
+}
+
+func divideIngestBulkRecords(records []IngestBulkRecord, parallelRoutines int) [][]IngestBulkRecord {
+	var divided [][]IngestBulkRecord
+	chunkSize := (len(records) + parallelRoutines - 1) / parallelRoutines
+	for i := 0; i < len(records); i += chunkSize {
+		end := i + chunkSize
+		if end > len(records) {
+			end = len(records)
+		}
+		divided = append(divided, records[i:end])
+	}
+	return divided
+}
+
+// runBulkOp dispatches records across a worker pool of persistent
+// connections, pipelining writes/reads per worker and retrying transient
+// errors, for the given verb (push or pop).
+func (i ingesterChannel) runBulkOp(ctx context.Context, verb ingesterCommands, collection, bucket string, parallelRoutines int, records []IngestBulkRecord) []IngestBulkError {
+	workers := i.bulk.workerPoolSize
+	if parallelRoutines > 0 {
+		workers = parallelRoutines
+	}
+	if workers <= 0 {
+		workers = 1
+	}
+
+	errs := make([]IngestBulkError, 0)
+	errMutex := &sync.Mutex{}
+
+	divided := divideIngestBulkRecords(records, workers)
+
+	group := sync.WaitGroup{}
+	group.Add(len(divided))
+	for _, recs := range divided {
+		go func(recs []IngestBulkRecord) {
+			defer group.Done()
+
+			progress := &chunkProgress{recs: recs}
+			i.life.trackChunk(progress)
+			defer i.life.untrackChunk(progress)
+
+			i.runBulkWorker(ctx, verb, collection, bucket, progress, &errs, errMutex)
+		}(recs)
+	}
+	group.Wait()
+
+	return errs
+}
+
+// runBulkWorker drains progress.recs on a single pooled connection, writing
+// up to bulk.pipelineDepth commands ahead of reading their replies, and
+// advancing progress.cursor as records are attempted so Drain can report the
+// unattempted remainder if it times out mid-flight.
+func (i ingesterChannel) runBulkWorker(ctx context.Context, verb ingesterCommands, collection, bucket string, progress *chunkProgress, errs *[]IngestBulkError, mutex *sync.Mutex) {
+	recs := progress.recs
+
+	conn, err := i.pool.get()
+	if err != nil {
+		i.addBulkErrors(ctx, errs, recs, err, mutex)
+		return
+	}
+
+	depth := i.bulk.pipelineDepth
+	if depth <= 0 {
+		depth = 1
+	}
+
+	for start := 0; start < len(recs); start += depth {
+		if err := ctx.Err(); err != nil {
+			i.addBulkErrors(ctx, errs, recs[start:], err, mutex)
+			progress.advance(len(recs) - start)
+			_ = conn.close()
+			return
+		}
+
+		end := start + depth
+		if end > len(recs) {
+			end = len(recs)
+		}
+		batch := recs[start:end]
+
+		// pipeline: write the whole batch before reading any of its replies back
+		sent := 0
+		for _, rec := range batch {
+			cmd := fmt.Sprintf("%s %s %s %s \"%s\"", verb, collection, bucket, rec.Object, rec.Text)
+			if err := i.bulkWriteWithRetry(ctx, conn, cmd); err != nil {
+				// recs[start+sent:] covers the rest of this batch plus every
+				// later batch this worker hasn't reached yet; none of them
+				// can be attempted on a broken connection.
+				i.addBulkErrors(ctx, errs, recs[start+sent:], err, mutex)
+				progress.advance(len(recs) - start)
+				_ = conn.close()
+				return
+			}
+			sent++
+		}
+
+		for idx, rec := range batch {
+			// sonic should sent OK
+			if _, err := i.bulkReadWithRetry(ctx, conn); err != nil {
+				i.addBulkError(ctx, errs, rec, err, mutex)
+				if isTransient(err) {
+					// replies for the rest of this batch were never read, and
+					// later batches were never attempted; both are stuck
+					// behind the same broken connection.
+					i.addBulkErrors(ctx, errs, recs[start+idx+1:], err, mutex)
+					progress.advance(len(recs) - start)
+					_ = conn.close()
+					return
+				}
+			}
+		}
+
+		progress.advance(end - start)
+	}
+
+	i.pool.put(conn)
+}
+
+func (i ingesterChannel) bulkWriteWithRetry(ctx context.Context, conn *driver, cmd string) error {
+	return withRetry(ctx, i.bulk.retryMax, i.bulk.retryBase, func() error {
+		return conn.writeContext(ctx, cmd)
+	})
+}
+
+func (i ingesterChannel) bulkReadWithRetry(ctx context.Context, conn *driver) (string, error) {
+	var reply string
+	err := withRetry(ctx, i.bulk.retryMax, i.bulk.retryBase, func() error {
+		r, err := conn.readContext(ctx)
+		reply = r
+		return err
+	})
+	return reply, err
+}
+
+// withRetry calls fn until it succeeds, returns a non-transient error, ctx is
+// done, or max attempts are exhausted, backing off exponentially from base
+// between attempts.
+func withRetry(ctx context.Context, max int, base time.Duration, fn func() error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = fn()
+		if err == nil || !isTransient(err) || attempt >= max {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(base * time.Duration(1<<attempt)):
+		}
+	}
+}
+
+// isTransient reports whether err is worth retrying: a closed connection, an
+// unexpected EOF, or a network timeout.
+func isTransient(err error) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, ErrClosed) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return netErr.Timeout()
+	}
+	return false
+}
+
+func (i ingesterChannel) addBulkError(ctx context.Context, e *[]IngestBulkError, record IngestBulkRecord, err error, mutex *sync.Mutex) {
+	mutex.Lock()
+	*e = append(*e, IngestBulkError{record.Object, err})
+	mutex.Unlock()
+
+	i.streamBulkError(ctx, IngestBulkError{record.Object, err})
+}
+
+// addBulkErrors records the same error against every record in recs, e.g. the
+// records left unattempted when a bulk operation is aborted by ctx.
+func (i ingesterChannel) addBulkErrors(ctx context.Context, e *[]IngestBulkError, recs []IngestBulkRecord, err error, mutex *sync.Mutex) {
+	for _, rec := range recs {
+		i.addBulkError(ctx, e, rec, err, mutex)
+	}
+}
+
+// streamBulkError forwards a single bulk error onto bulk.errCh, if the
+// caller configured one via WithBulkErrorChannel.
+func (i ingesterChannel) streamBulkError(ctx context.Context, bulkErr IngestBulkError) {
+	if i.bulk.errCh == nil {
+		return
+	}
+	select {
+	case i.bulk.errCh <- bulkErr:
+	case <-ctx.Done():
+	}
+}
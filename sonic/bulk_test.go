@@ -0,0 +1,177 @@
+package sonic
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestIsTransient(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"eof", io.EOF, true},
+		{"closed", ErrClosed, true},
+		{"timeout", timeoutErr{}, true},
+		{"other", errors.New("boom"), false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := isTransient(c.err); got != c.want {
+				t.Fatalf("isTransient(%v) = %v, want %v", c.err, got, c.want)
+			}
+		})
+	}
+}
+
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string   { return "timeout" }
+func (timeoutErr) Timeout() bool   { return true }
+func (timeoutErr) Temporary() bool { return true }
+
+func TestWithRetryStopsOnNonTransientError(t *testing.T) {
+	attempts := 0
+	err := withRetry(context.Background(), 2, time.Millisecond, func() error {
+		attempts++
+		return errors.New("permanent")
+	})
+	if err == nil || err.Error() != "permanent" {
+		t.Fatalf("withRetry err = %v, want permanent", err)
+	}
+	if attempts != 1 {
+		t.Fatalf("attempts = %d, want 1 (no retry on a non-transient error)", attempts)
+	}
+}
+
+func TestWithRetryRetriesTransientErrorUpToMax(t *testing.T) {
+	attempts := 0
+	err := withRetry(context.Background(), 2, time.Millisecond, func() error {
+		attempts++
+		return io.EOF
+	})
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("withRetry err = %v, want io.EOF", err)
+	}
+	if attempts != 3 {
+		t.Fatalf("attempts = %d, want 3 (1 initial + 2 retries)", attempts)
+	}
+}
+
+func TestWithRetrySucceedsAfterTransientError(t *testing.T) {
+	attempts := 0
+	err := withRetry(context.Background(), 2, time.Millisecond, func() error {
+		attempts++
+		if attempts < 2 {
+			return io.EOF
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("withRetry err = %v, want nil", err)
+	}
+	if attempts != 2 {
+		t.Fatalf("attempts = %d, want 2", attempts)
+	}
+}
+
+func TestWithRetryAbortsOnCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	attempts := 0
+	err := withRetry(ctx, 2, time.Second, func() error {
+		attempts++
+		return io.EOF
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("withRetry err = %v, want context.Canceled", err)
+	}
+	if attempts != 1 {
+		t.Fatalf("attempts = %d, want 1 (no retry once ctx is done)", attempts)
+	}
+}
+
+func TestChunkProgressAdvanceAndRemaining(t *testing.T) {
+	recs := []IngestBulkRecord{{Object: "a"}, {Object: "b"}, {Object: "c"}}
+	p := &chunkProgress{recs: recs}
+
+	if got := p.remaining(); len(got) != 3 {
+		t.Fatalf("remaining before any advance = %d records, want 3", len(got))
+	}
+
+	p.advance(1)
+	if got := p.remaining(); len(got) != 2 || got[0].Object != "b" {
+		t.Fatalf("remaining after advance(1) = %+v, want [b c]", got)
+	}
+
+	p.advance(2)
+	if got := p.remaining(); got != nil {
+		t.Fatalf("remaining after fully advanced = %+v, want nil", got)
+	}
+}
+
+func TestDivideIngestBulkRecords(t *testing.T) {
+	recs := make([]IngestBulkRecord, 10)
+	for i := range recs {
+		recs[i] = IngestBulkRecord{Object: string(rune('a' + i))}
+	}
+
+	divided := divideIngestBulkRecords(recs, 3)
+	if len(divided) != 4 {
+		t.Fatalf("len(divided) = %d, want 4 chunks of at most 3", len(divided))
+	}
+
+	var total int
+	for _, chunk := range divided {
+		total += len(chunk)
+	}
+	if total != len(recs) {
+		t.Fatalf("divided records total = %d, want %d", total, len(recs))
+	}
+}
+
+func TestConnPoolGetReturnsPooledConnection(t *testing.T) {
+	pool := newConnPool(&driver{}, 2)
+	local, remote := net.Pipe()
+	defer remote.Close()
+	d := &driver{conn: local}
+	pool.put(d)
+
+	got, err := pool.get()
+	if err != nil {
+		t.Fatalf("get() err = %v", err)
+	}
+	if got != d {
+		t.Fatal("get() did not return the pooled connection")
+	}
+}
+
+func TestConnPoolCloseAllClosesIdleConnections(t *testing.T) {
+	pool := newConnPool(&driver{}, 2)
+	local1, remote1 := net.Pipe()
+	defer remote1.Close()
+	local2, remote2 := net.Pipe()
+	defer remote2.Close()
+
+	d1 := &driver{conn: local1}
+	d2 := &driver{conn: local2}
+	pool.put(d1)
+	pool.put(d2)
+
+	pool.closeAll()
+
+	if !d1.isClosed || !d2.isClosed {
+		t.Fatal("closeAll did not close every pooled connection")
+	}
+	select {
+	case <-pool.pool:
+		t.Fatal("expected the pool to be empty after closeAll")
+	default:
+	}
+}
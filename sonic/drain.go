@@ -0,0 +1,117 @@
+package sonic
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// ErrDraining is returned by Push, Pop, Count, the Flush* calls and the bulk
+// and context variants of them once Drain has been called: the ingester no
+// longer accepts new work.
+var ErrDraining = fmt.Errorf("sonic: ingester is draining")
+
+// DrainIncompleteError is returned by Drain when its context is done before
+// every in-flight BulkPush/BulkPop finished. Pending holds the records each
+// such operation had not yet attempted, so the caller can hand them off to
+// another process instead of losing them.
+type DrainIncompleteError struct {
+	Pending []IngestBulkRecord
+}
+
+func (e *DrainIncompleteError) Error() string {
+	return fmt.Sprintf("sonic: drain incomplete, %d record(s) pending", len(e.Pending))
+}
+
+// lifecycle coordinates graceful shutdown across every ingesterChannel value
+// sharing the same underlying driver: it is held by pointer so copies of
+// ingesterChannel (e.g. returned as the Ingestable interface) all observe the
+// same draining state and in-flight call count.
+type lifecycle struct {
+	mu       sync.Mutex
+	draining bool
+
+	calls  sync.WaitGroup // top-level Push/Pop/Count/Flush*/Bulk* calls in flight
+	chunks map[*chunkProgress]struct{}
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{chunks: make(map[*chunkProgress]struct{})}
+}
+
+// enter admits one top-level call, or rejects it with ErrDraining once Drain
+// has started. Every accepted call must invoke leave exactly once.
+func (l *lifecycle) enter() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.draining {
+		return ErrDraining
+	}
+	l.calls.Add(1)
+	return nil
+}
+
+func (l *lifecycle) leave() {
+	l.calls.Done()
+}
+
+func (l *lifecycle) trackChunk(c *chunkProgress) {
+	l.mu.Lock()
+	l.chunks[c] = struct{}{}
+	l.mu.Unlock()
+}
+
+func (l *lifecycle) untrackChunk(c *chunkProgress) {
+	l.mu.Lock()
+	delete(l.chunks, c)
+	l.mu.Unlock()
+}
+
+// pending returns the still-unattempted records of every currently tracked
+// chunk.
+func (l *lifecycle) pending() []IngestBulkRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var pending []IngestBulkRecord
+	for c := range l.chunks {
+		pending = append(pending, c.remaining()...)
+	}
+	return pending
+}
+
+// Drain stops the ingester from accepting new Push/Pop/Count/Flush* calls
+// (and their bulk and context variants), waits for calls already in flight
+// to finish up to ctx's deadline, and then closes the underlying connection
+// by sending QUIT. If ctx is done first, Drain closes only the idle pooled
+// bulk connections, leaves the underlying connection open, and returns a
+// *DrainIncompleteError listing the bulk records that hadn't been attempted
+// yet, so the caller can hand them off elsewhere, or ctx.Err() when no such
+// records are pending.
+func (i ingesterChannel) Drain(ctx context.Context) error {
+	i.life.mu.Lock()
+	if i.life.draining {
+		i.life.mu.Unlock()
+		return ErrDraining
+	}
+	i.life.draining = true
+	i.life.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		i.life.calls.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		i.pool.closeAll()
+		return i.driver.Quit()
+	case <-ctx.Done():
+		i.pool.closeAll()
+		if pending := i.life.pending(); len(pending) > 0 {
+			return &DrainIncompleteError{Pending: pending}
+		}
+		return ctx.Err()
+	}
+}
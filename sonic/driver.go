@@ -0,0 +1,238 @@
+package sonic
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// channel identifies which Sonic channel (search, ingest, control) a
+// connection speaks once the START handshake has completed.
+type channel string
+
+const (
+	Search  channel = "search"
+	Ingest  channel = "ingest"
+	Control channel = "control"
+)
+
+// ErrClosed is returned by read/write when the connection has already
+// been closed locally, via Quit or close. A connection closed by the remote
+// end surfaces as the underlying read/write error (e.g. io.EOF) instead.
+var ErrClosed = errors.New("sonic: connection is closed")
+
+const defaultBufferSize = 20000
+
+// driver owns a single TCP connection to a Sonic server and implements the
+// line based read/write protocol shared by the search, ingest and control
+// channels.
+type driver struct {
+	Host     string
+	Port     int
+	Password string
+	channel  channel
+
+	conn   net.Conn
+	reader *bufio.Reader
+
+	cmdMaxBytes int
+	bufferSize  int
+
+	isClosed bool
+}
+
+// Connect dials the Sonic server and performs the START handshake for the
+// configured channel, storing the server-advertised cmdMaxBytes.
+func (d *driver) Connect() error {
+	conn, err := net.Dial("tcp", fmt.Sprintf("%s:%d", d.Host, d.Port))
+	if err != nil {
+		return err
+	}
+	d.conn = conn
+	d.bufferSize = defaultBufferSize
+	d.reader = bufio.NewReaderSize(conn, d.bufferSize)
+
+	// CONNECTED <server>
+	if _, err := d.read(); err != nil {
+		return err
+	}
+
+	if err := d.write(fmt.Sprintf("START %s %s", d.channel, d.Password)); err != nil {
+		return err
+	}
+
+	// STARTED <channel> protocol(<version>) buffer(<cmdMaxBytes>)
+	started, err := d.read()
+	if err != nil {
+		return err
+	}
+	d.cmdMaxBytes = parseCmdMaxBytes(started)
+
+	return nil
+}
+
+func parseCmdMaxBytes(started string) int {
+	const marker = "buffer("
+	i := strings.Index(started, marker)
+	if i == -1 {
+		return defaultBufferSize
+	}
+	i += len(marker)
+	j := strings.IndexByte(started[i:], ')')
+	if j == -1 {
+		return defaultBufferSize
+	}
+	var n int
+	if _, err := fmt.Sscanf(started[i:i+j], "%d", &n); err != nil || n <= 0 {
+		return defaultBufferSize
+	}
+	return n
+}
+
+func (d *driver) write(cmd string) error {
+	if d.isClosed {
+		return ErrClosed
+	}
+	_, err := d.conn.Write([]byte(cmd + "\r\n"))
+	return err
+}
+
+func (d *driver) read() (string, error) {
+	if d.isClosed {
+		return "", ErrClosed
+	}
+	line, err := d.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if strings.HasPrefix(line, "ERR ") {
+		return "", errors.New(line)
+	}
+	return line, nil
+}
+
+// writeContext behaves like write but honors ctx: it applies ctx's deadline
+// (if any) to the underlying connection before writing, and also watches
+// ctx.Done() for the duration of the write so a cancel-only context (no
+// deadline) still interrupts an in-flight write instead of blocking until it
+// naturally completes.
+func (d *driver) writeContext(ctx context.Context, cmd string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := d.applyDeadline(ctx); err != nil {
+		return err
+	}
+
+	stop := d.watchCancel(ctx)
+	err := d.write(cmd)
+	stop()
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// readContext behaves like read but honors ctx the same way writeContext does.
+func (d *driver) readContext(ctx context.Context) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if err := d.applyDeadline(ctx); err != nil {
+		return "", err
+	}
+
+	stop := d.watchCancel(ctx)
+	line, err := d.read()
+	stop()
+	if err != nil && ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	return line, err
+}
+
+// applyDeadline sets (or clears) the connection's deadline to match ctx.
+func (d *driver) applyDeadline(ctx context.Context) error {
+	if d.isClosed {
+		return ErrClosed
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Time{}
+	}
+	return d.conn.SetDeadline(deadline)
+}
+
+// watchCancel arms a goroutine that forces the connection's deadline into
+// the past as soon as ctx is done, unblocking a Read/Write already in
+// flight even when ctx carries no deadline of its own (e.g. a
+// context.WithCancel). The caller must invoke the returned stop func once
+// its I/O call returns, or the goroutine leaks until ctx is done.
+func (d *driver) watchCancel(ctx context.Context) (stop func()) {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = d.conn.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+func (d *driver) close() error {
+	if d.isClosed {
+		return nil
+	}
+	d.isClosed = true
+	return d.conn.Close()
+}
+
+// Quit sends QUIT and closes the underlying connection.
+func (d *driver) Quit() (err error) {
+	err = d.write("QUIT")
+	if err != nil {
+		return err
+	}
+	// ENDED <channel>
+	_, err = d.read()
+	if closeErr := d.close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Ping sends PING and waits for the PONG reply.
+func (d *driver) Ping() (err error) {
+	err = d.write("PING")
+	if err != nil {
+		return err
+	}
+	_, err = d.read()
+	return err
+}
+
+// newConnection opens an additional connection to the same Sonic server and
+// channel as d, for callers (e.g. BulkPush/BulkPop) that need a dedicated
+// connection per goroutine.
+func newConnection(d *driver) (*driver, error) {
+	conn := &driver{
+		Host:     d.Host,
+		Port:     d.Port,
+		Password: d.Password,
+		channel:  d.channel,
+	}
+	if err := conn.Connect(); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
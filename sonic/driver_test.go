@@ -0,0 +1,74 @@
+package sonic
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestWriteContextCancellation(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+	d := &driver{conn: local}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- d.writeContext(ctx, "PING") }()
+
+	// give the write a moment to block on the pipe before cancelling, since
+	// nothing ever reads the other end.
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("writeContext error = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("writeContext did not return after ctx was cancelled")
+	}
+}
+
+func TestReadContextCancellation(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+	d := &driver{conn: local, reader: bufio.NewReader(local)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := d.readContext(ctx)
+		errCh <- err
+	}()
+
+	// nothing is ever written on the pipe, so the read blocks until cancelled.
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("readContext error = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("readContext did not return after ctx was cancelled")
+	}
+}
+
+func TestWriteContextAlreadyDone(t *testing.T) {
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+	d := &driver{conn: local}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := d.writeContext(ctx, "PING"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("writeContext on an already-cancelled ctx = %v, want context.Canceled", err)
+	}
+}
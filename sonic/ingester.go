@@ -1,10 +1,11 @@
 package sonic
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
-	"sync"
+	"time"
 	"unicode/utf8"
 )
 
@@ -25,44 +26,85 @@ type Ingestable interface {
 	// Command syntax PUSH <collection> <bucket> <object> "<text>"
 	Push(collection, bucket, object, text string) (err error)
 
+	// PushContext is like Push but aborts as soon as ctx is done, propagating
+	// ctx's deadline to the underlying connection.
+	PushContext(ctx context.Context, collection, bucket, object, text string) (err error)
+
 	// BulkPush will execute N (parallelRoutines) goroutines at the same time to
 	// dispatch the records at best.
 	// If parallelRoutines <= 0; parallelRoutines will be equal to 1.
 	// If parallelRoutines > len(records); parallelRoutines will be equal to len(records).
 	BulkPush(collection, bucket string, parallelRoutines int, records []IngestBulkRecord) []IngestBulkError
 
+	// BulkPushContext is like BulkPush but stops dispatching new records and
+	// returns as soon as ctx is done, instead of draining the whole records
+	// slice. Records not yet attempted are reported as errors with ctx.Err().
+	BulkPushContext(ctx context.Context, collection, bucket string, parallelRoutines int, records []IngestBulkRecord) []IngestBulkError
+
 	// Pop search data from the index.
 	// Command syntax POP <collection> <bucket> <object> "<text>".
 	Pop(collection, bucket, object, text string) (err error)
 
+	// PopContext is like Pop but aborts as soon as ctx is done, propagating
+	// ctx's deadline to the underlying connection.
+	PopContext(ctx context.Context, collection, bucket, object, text string) (err error)
+
 	// BulkPop will execute N (parallelRoutines) goroutines at the same time to
 	// dispatch the records at best.
 	// If parallelRoutines <= 0; parallelRoutines will be equal to 1.
 	// If parallelRoutines > len(records); parallelRoutines will be equal to len(records).
 	BulkPop(collection, bucket string, parallelRoutines int, records []IngestBulkRecord) []IngestBulkError
 
+	// BulkPopContext is like BulkPop but stops dispatching new records and
+	// returns as soon as ctx is done, instead of draining the whole records
+	// slice. Records not yet attempted are reported as errors with ctx.Err().
+	BulkPopContext(ctx context.Context, collection, bucket string, parallelRoutines int, records []IngestBulkRecord) []IngestBulkError
+
 	// Count indexed search data.
 	// bucket and object are optionals, empty string ignore it.
 	// Command syntax COUNT <collection> [<bucket> [<object>]?]?.
 	Count(collection, bucket, object string) (count int, err error)
 
+	// CountContext is like Count but aborts as soon as ctx is done, propagating
+	// ctx's deadline to the underlying connection.
+	CountContext(ctx context.Context, collection, bucket, object string) (count int, err error)
+
 	// FlushCollection Flush all indexed data from a collection.
 	// Command syntax FLUSHC <collection>.
 	FlushCollection(collection string) (err error)
 
+	// FlushCollectionContext is like FlushCollection but aborts as soon as ctx
+	// is done, propagating ctx's deadline to the underlying connection.
+	FlushCollectionContext(ctx context.Context, collection string) (err error)
+
 	// Flush all indexed data from a bucket in a collection.
 	// Command syntax FLUSHB <collection> <bucket>.
 	FlushBucket(collection, bucket string) (err error)
 
+	// FlushBucketContext is like FlushBucket but aborts as soon as ctx is done,
+	// propagating ctx's deadline to the underlying connection.
+	FlushBucketContext(ctx context.Context, collection, bucket string) (err error)
+
 	// Flush all indexed data from an object in a bucket in collection.
 	// Command syntax FLUSHO <collection> <bucket> <object>.
 	FlushObject(collection, bucket, object string) (err error)
 
+	// FlushObjectContext is like FlushObject but aborts as soon as ctx is done,
+	// propagating ctx's deadline to the underlying connection.
+	FlushObjectContext(ctx context.Context, collection, bucket, object string) (err error)
+
 	// Quit refer to the Base interface
 	Quit() (err error)
 
 	// Ping refer to the Base interface
 	Ping() (err error)
+
+	// Drain stops accepting new Push/Pop/Count/Flush*/Bulk* calls, waits for
+	// calls already in flight to complete up to ctx's deadline, then closes the
+	// connection. If ctx is done first, the connection is left open and Drain
+	// returns a *DrainIncompleteError listing the bulk records that hadn't been
+	// attempted yet, or ctx.Err() when none are pending.
+	Drain(ctx context.Context) (err error)
 }
 type ingesterCommands string
 
@@ -77,11 +119,14 @@ const (
 
 type ingesterChannel struct {
 	*driver
+	bulk bulkConfig
+	pool *connPool
+	life *lifecycle
 }
 
 // NewIngester create a new driver instance with a ingesterChannel instance.
 // Only way to get a Ingestable implementation.
-func NewIngester(host string, port int, password string) (Ingestable, error) {
+func NewIngester(host string, port int, password string, opts ...IngesterOption) (Ingestable, error) {
 	driver := &driver{
 		Host:     host,
 		Port:     port,
@@ -92,12 +137,42 @@ func NewIngester(host string, port int, password string) (Ingestable, error) {
 	if err != nil {
 		return nil, err
 	}
-	return ingesterChannel{
+
+	i := ingesterChannel{
 		driver: driver,
-	}, nil
+		bulk:   defaultBulkConfig(),
+		life:   newLifecycle(),
+	}
+	for _, opt := range opts {
+		opt(&i.bulk)
+	}
+	i.pool = newConnPool(driver, i.bulk.workerPoolSize)
+
+	return i, nil
 }
 
 func (i ingesterChannel) Push(collection, bucket, object, text string) (err error) {
+	return i.pushContext(context.Background(), collection, bucket, object, text)
+}
+
+// PushContext is like Push but aborts as soon as ctx is done.
+func (i ingesterChannel) PushContext(ctx context.Context, collection, bucket, object, text string) (err error) {
+	return i.pushContext(ctx, collection, bucket, object, text)
+}
+
+func (i ingesterChannel) pushContext(ctx context.Context, collection, bucket, object, text string) (err error) {
+	if err := i.life.enter(); err != nil {
+		return err
+	}
+	defer i.life.leave()
+
+	start := time.Now()
+	ctx, endSpan := startSpan(ctx, "push")
+	defer func() {
+		endSpan(err)
+		DefaultMetrics.ObserveOp("push", time.Since(start), err)
+	}()
+
 	//
 	patterns := []struct {
 		Pattern string
@@ -112,14 +187,14 @@ func (i ingesterChannel) Push(collection, bucket, object, text string) (err erro
 	chunks := splitText(text, i.cmdMaxBytes/2)
 	// split chunks with partial success will yield single error
 	for _, chunk := range chunks {
-		err = i.write(fmt.Sprintf("%s %s %s %s \"%s\"", push, collection, bucket, object, chunk))
+		err = i.writeContext(ctx, fmt.Sprintf("%s %s %s %s \"%s\"", push, collection, bucket, object, chunk))
 
 		if err != nil {
 			return err
 		}
 
 		// sonic should sent OK
-		_, err = i.read()
+		_, err = i.readContext(ctx)
 		if err != nil {
 			return err
 		}
@@ -150,54 +225,61 @@ func splitText(longString string, maxLen int) []string {
 }
 
 func (i ingesterChannel) BulkPush(collection, bucket string, parallelRoutines int, records []IngestBulkRecord) (errs []IngestBulkError) {
-	if parallelRoutines <= 0 {
-		parallelRoutines = 1
-	}
+	return i.bulkPushContext(context.Background(), collection, bucket, parallelRoutines, records)
+}
+
+// BulkPushContext is like BulkPush but each worker goroutine stops dispatching
+// further records and returns as soon as ctx is done, instead of draining the
+// whole records slice. Records not yet attempted are reported with ctx.Err().
+func (i ingesterChannel) BulkPushContext(ctx context.Context, collection, bucket string, parallelRoutines int, records []IngestBulkRecord) (errs []IngestBulkError) {
+	return i.bulkPushContext(ctx, collection, bucket, parallelRoutines, records)
+}
 
-	errs = make([]IngestBulkError, 0)
-	errMutex := &sync.Mutex{}
-
-	// chunk array into N (parallelRoutines) parts
-	divided := divideIngestBulkRecords(records, parallelRoutines)
-
-	// dispatch each records array into N goroutines
-	group := sync.WaitGroup{}
-	group.Add(len(divided))
-	for _, r := range divided {
-		go func(recs []IngestBulkRecord) {
-			conn, _ := newConnection(i.driver)
-
-			for _, rec := range recs {
-				if conn == nil {
-					addBulkError(&errs, rec, ErrClosed, errMutex)
-				}
-				err := i.Push(collection, bucket, rec.Object, rec.Text)
-				if err != nil {
-					addBulkError(&errs, rec, err, errMutex)
-					continue
-				}
-				// sonic should sent OK
-				_, err = conn.read()
-				if err != nil {
-					addBulkError(&errs, rec, err, errMutex)
-				}
-			}
-			conn.close()
-			group.Done()
-		}(r)
+func (i ingesterChannel) bulkPushContext(ctx context.Context, collection, bucket string, parallelRoutines int, records []IngestBulkRecord) (errs []IngestBulkError) {
+	if err := i.life.enter(); err != nil {
+		return []IngestBulkError{{Error: err}}
 	}
-	group.Wait()
-	return errs
+	defer i.life.leave()
+
+	start := time.Now()
+	ctx, endSpan := startSpan(ctx, "bulk_push")
+	defer func() {
+		endSpan(firstBulkErr(errs))
+		DefaultMetrics.ObserveBulk("bulk_push", len(records), len(errs), time.Since(start))
+	}()
+
+	return i.runBulkOp(ctx, push, collection, bucket, parallelRoutines, records)
 }
 
 func (i ingesterChannel) Pop(collection, bucket, object, text string) (err error) {
-	err = i.write(fmt.Sprintf("%s %s %s %s \"%s\"", pop, collection, bucket, object, text))
+	return i.popContext(context.Background(), collection, bucket, object, text)
+}
+
+// PopContext is like Pop but aborts as soon as ctx is done.
+func (i ingesterChannel) PopContext(ctx context.Context, collection, bucket, object, text string) (err error) {
+	return i.popContext(ctx, collection, bucket, object, text)
+}
+
+func (i ingesterChannel) popContext(ctx context.Context, collection, bucket, object, text string) (err error) {
+	if err := i.life.enter(); err != nil {
+		return err
+	}
+	defer i.life.leave()
+
+	start := time.Now()
+	ctx, endSpan := startSpan(ctx, "pop")
+	defer func() {
+		endSpan(err)
+		DefaultMetrics.ObserveOp("pop", time.Since(start), err)
+	}()
+
+	err = i.writeContext(ctx, fmt.Sprintf("%s %s %s %s \"%s\"", pop, collection, bucket, object, text))
 	if err != nil {
 		return err
 	}
 
 	// sonic should sent OK
-	_, err = i.read()
+	_, err = i.readContext(ctx)
 	if err != nil {
 		return err
 	}
@@ -205,57 +287,61 @@ func (i ingesterChannel) Pop(collection, bucket, object, text string) (err error
 }
 
 func (i ingesterChannel) BulkPop(collection, bucket string, parallelRoutines int, records []IngestBulkRecord) (errs []IngestBulkError) {
-	if parallelRoutines <= 0 {
-		parallelRoutines = 1
-	}
+	return i.bulkPopContext(context.Background(), collection, bucket, parallelRoutines, records)
+}
+
+// BulkPopContext is like BulkPop but each worker goroutine stops dispatching
+// further records and returns as soon as ctx is done, instead of draining the
+// whole records slice. Records not yet attempted are reported with ctx.Err().
+func (i ingesterChannel) BulkPopContext(ctx context.Context, collection, bucket string, parallelRoutines int, records []IngestBulkRecord) (errs []IngestBulkError) {
+	return i.bulkPopContext(ctx, collection, bucket, parallelRoutines, records)
+}
 
-	errs = make([]IngestBulkError, 0)
-	errMutex := &sync.Mutex{}
-
-	// chunk array into N (parallelRoutines) parts
-	divided := divideIngestBulkRecords(records, parallelRoutines)
-
-	// dispatch each records array into N goroutines
-	group := sync.WaitGroup{}
-	group.Add(len(divided))
-	for _, r := range divided {
-		go func(recs []IngestBulkRecord) {
-			conn, _ := newConnection(i.driver)
-
-			for _, rec := range recs {
-				if conn == nil {
-					addBulkError(&errs, rec, ErrClosed, errMutex)
-				}
-				err := conn.write(fmt.Sprintf(
-					"%s %s %s %s \"%s\"",
-					pop, collection, bucket, rec.Object, rec.Text),
-				)
-				if err != nil {
-					addBulkError(&errs, rec, err, errMutex)
-					continue
-				}
-				// sonic should sent OK
-				_, err = conn.read()
-				if err != nil {
-					addBulkError(&errs, rec, err, errMutex)
-				}
-			}
-			conn.close()
-			group.Done()
-		}(r)
+func (i ingesterChannel) bulkPopContext(ctx context.Context, collection, bucket string, parallelRoutines int, records []IngestBulkRecord) (errs []IngestBulkError) {
+	if err := i.life.enter(); err != nil {
+		return []IngestBulkError{{Error: err}}
 	}
-	group.Wait()
-	return errs
+	defer i.life.leave()
+
+	start := time.Now()
+	ctx, endSpan := startSpan(ctx, "bulk_pop")
+	defer func() {
+		endSpan(firstBulkErr(errs))
+		DefaultMetrics.ObserveBulk("bulk_pop", len(records), len(errs), time.Since(start))
+	}()
+
+	return i.runBulkOp(ctx, pop, collection, bucket, parallelRoutines, records)
 }
 
 func (i ingesterChannel) Count(collection, bucket, object string) (cnt int, err error) {
-	err = i.write(fmt.Sprintf("%s %s %s", count, collection, buildCountQuery(bucket, object)))
+	return i.countContext(context.Background(), collection, bucket, object)
+}
+
+// CountContext is like Count but aborts as soon as ctx is done.
+func (i ingesterChannel) CountContext(ctx context.Context, collection, bucket, object string) (cnt int, err error) {
+	return i.countContext(ctx, collection, bucket, object)
+}
+
+func (i ingesterChannel) countContext(ctx context.Context, collection, bucket, object string) (cnt int, err error) {
+	if err := i.life.enter(); err != nil {
+		return 0, err
+	}
+	defer i.life.leave()
+
+	start := time.Now()
+	ctx, endSpan := startSpan(ctx, "count")
+	defer func() {
+		endSpan(err)
+		DefaultMetrics.ObserveOp("count", time.Since(start), err)
+	}()
+
+	err = i.writeContext(ctx, fmt.Sprintf("%s %s %s", count, collection, buildCountQuery(bucket, object)))
 	if err != nil {
 		return 0, err
 	}
 
 	// RESULT NUMBER
-	r, err := i.read()
+	r, err := i.readContext(ctx)
 	if err != nil {
 		return 0, err
 	}
@@ -274,13 +360,34 @@ func buildCountQuery(bucket, object string) string {
 }
 
 func (i ingesterChannel) FlushCollection(collection string) (err error) {
-	err = i.write(fmt.Sprintf("%s %s", flushc, collection))
+	return i.flushCollectionContext(context.Background(), collection)
+}
+
+// FlushCollectionContext is like FlushCollection but aborts as soon as ctx is done.
+func (i ingesterChannel) FlushCollectionContext(ctx context.Context, collection string) (err error) {
+	return i.flushCollectionContext(ctx, collection)
+}
+
+func (i ingesterChannel) flushCollectionContext(ctx context.Context, collection string) (err error) {
+	if err := i.life.enter(); err != nil {
+		return err
+	}
+	defer i.life.leave()
+
+	start := time.Now()
+	ctx, endSpan := startSpan(ctx, "flush_collection")
+	defer func() {
+		endSpan(err)
+		DefaultMetrics.ObserveOp("flush_collection", time.Since(start), err)
+	}()
+
+	err = i.writeContext(ctx, fmt.Sprintf("%s %s", flushc, collection))
 	if err != nil {
 		return err
 	}
 
 	// sonic should sent OK
-	_, err = i.read()
+	_, err = i.readContext(ctx)
 	if err != nil {
 		return err
 	}
@@ -288,13 +395,34 @@ func (i ingesterChannel) FlushCollection(collection string) (err error) {
 }
 
 func (i ingesterChannel) FlushBucket(collection, bucket string) (err error) {
-	err = i.write(fmt.Sprintf("%s %s %s", flushb, collection, bucket))
+	return i.flushBucketContext(context.Background(), collection, bucket)
+}
+
+// FlushBucketContext is like FlushBucket but aborts as soon as ctx is done.
+func (i ingesterChannel) FlushBucketContext(ctx context.Context, collection, bucket string) (err error) {
+	return i.flushBucketContext(ctx, collection, bucket)
+}
+
+func (i ingesterChannel) flushBucketContext(ctx context.Context, collection, bucket string) (err error) {
+	if err := i.life.enter(); err != nil {
+		return err
+	}
+	defer i.life.leave()
+
+	start := time.Now()
+	ctx, endSpan := startSpan(ctx, "flush_bucket")
+	defer func() {
+		endSpan(err)
+		DefaultMetrics.ObserveOp("flush_bucket", time.Since(start), err)
+	}()
+
+	err = i.writeContext(ctx, fmt.Sprintf("%s %s %s", flushb, collection, bucket))
 	if err != nil {
 		return err
 	}
 
 	// sonic should sent OK
-	_, err = i.read()
+	_, err = i.readContext(ctx)
 	if err != nil {
 		return err
 	}
@@ -302,34 +430,37 @@ func (i ingesterChannel) FlushBucket(collection, bucket string) (err error) {
 }
 
 func (i ingesterChannel) FlushObject(collection, bucket, object string) (err error) {
-	err = i.write(fmt.Sprintf("%s %s %s %s", flusho, collection, bucket, object))
+	return i.flushObjectContext(context.Background(), collection, bucket, object)
+}
+
+// FlushObjectContext is like FlushObject but aborts as soon as ctx is done.
+func (i ingesterChannel) FlushObjectContext(ctx context.Context, collection, bucket, object string) (err error) {
+	return i.flushObjectContext(ctx, collection, bucket, object)
+}
+
+func (i ingesterChannel) flushObjectContext(ctx context.Context, collection, bucket, object string) (err error) {
+	if err := i.life.enter(); err != nil {
+		return err
+	}
+	defer i.life.leave()
+
+	start := time.Now()
+	ctx, endSpan := startSpan(ctx, "flush_object")
+	defer func() {
+		endSpan(err)
+		DefaultMetrics.ObserveOp("flush_object", time.Since(start), err)
+	}()
+
+	err = i.writeContext(ctx, fmt.Sprintf("%s %s %s %s", flusho, collection, bucket, object))
 	if err != nil {
 		return err
 	}
 
 	// sonic should sent OK
-	_, err = i.read()
+	_, err = i.readContext(ctx)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func divideIngestBulkRecords(records []IngestBulkRecord, parallelRoutines int) [][]IngestBulkRecord {
-	var divided [][]IngestBulkRecord
-	chunkSize := (len(records) + parallelRoutines - 1) / parallelRoutines
-	for i := 0; i < len(records); i += chunkSize {
-		end := i + chunkSize
-		if end > len(records) {
-			end = len(records)
-		}
-		divided = append(divided, records[i:end])
-	}
-	return divided
-}
-
-func addBulkError(e *[]IngestBulkError, record IngestBulkRecord, err error, mutex *sync.Mutex) {
-	mutex.Lock()
-	defer mutex.Unlock()
-	*e = append(*e, IngestBulkError{record.Object, err})
-}
@@ -0,0 +1,82 @@
+// Package consistenthash implements a consistent hashing ring so that
+// adding or removing a node only redistributes roughly 1/N of the keys,
+// instead of reshuffling everything as a plain mod-N hash would.
+package consistenthash
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// Hash hashes data to a uint32, used to place both nodes and keys on the ring.
+type Hash func(data []byte) uint32
+
+// defaultReplicas is the number of virtual nodes placed on the ring per
+// added node when the caller doesn't override it.
+const defaultReplicas = 150
+
+// Map is a consistent hash ring of virtual node IDs mapped back to the real
+// node name that owns them. It is not safe for concurrent use; callers that
+// mutate the ring after construction must provide their own synchronization.
+type Map struct {
+	hash     Hash
+	replicas int
+	keys     []uint32 // sorted
+	hashMap  map[uint32]string
+}
+
+// New creates a Map with the given number of virtual nodes per added node.
+// If replicas <= 0, defaultReplicas is used. If fn is nil, FNV-1a 32-bit is used.
+func New(replicas int, fn Hash) *Map {
+	if replicas <= 0 {
+		replicas = defaultReplicas
+	}
+	if fn == nil {
+		fn = fnv32a
+	}
+	return &Map{
+		hash:     fn,
+		replicas: replicas,
+		hashMap:  make(map[uint32]string),
+	}
+}
+
+func fnv32a(data []byte) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write(data)
+	return h.Sum32()
+}
+
+// IsEmpty reports whether the ring has no nodes.
+func (m *Map) IsEmpty() bool {
+	return len(m.keys) == 0
+}
+
+// Add places replicas virtual nodes for each of the given node names onto the ring.
+func (m *Map) Add(nodes ...string) {
+	for _, node := range nodes {
+		for i := 0; i < m.replicas; i++ {
+			hash := m.hash([]byte(strconv.Itoa(i) + node))
+			m.keys = append(m.keys, hash)
+			m.hashMap[hash] = node
+		}
+	}
+	sort.Slice(m.keys, func(i, j int) bool { return m.keys[i] < m.keys[j] })
+}
+
+// Get returns the node owning key: the first virtual node clockwise from
+// key's hash, wrapping around to the first virtual node on the ring.
+func (m *Map) Get(key string) string {
+	if m.IsEmpty() {
+		return ""
+	}
+
+	hash := m.hash([]byte(key))
+	idx := sort.Search(len(m.keys), func(i int) bool { return m.keys[i] >= hash })
+	if idx == len(m.keys) {
+		idx = 0
+	}
+
+	return m.hashMap[m.keys[idx]]
+}
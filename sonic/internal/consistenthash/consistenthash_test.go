@@ -0,0 +1,91 @@
+package consistenthash
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestMapEmpty(t *testing.T) {
+	m := New(0, nil)
+	if !m.IsEmpty() {
+		t.Fatal("expected a freshly constructed Map to be empty")
+	}
+	if got := m.Get("anything"); got != "" {
+		t.Fatalf("Get on an empty Map = %q, want empty string", got)
+	}
+}
+
+func TestMapGetIsStable(t *testing.T) {
+	m := New(0, nil)
+	m.Add("a", "b", "c")
+
+	keys := []string{"one", "two", "three", "four", "five"}
+	want := make(map[string]string, len(keys))
+	for _, k := range keys {
+		want[k] = m.Get(k)
+	}
+
+	for i := 0; i < 10; i++ {
+		for _, k := range keys {
+			if got := m.Get(k); got != want[k] {
+				t.Fatalf("Get(%q) = %q on repeat call, want %q", k, got, want[k])
+			}
+		}
+	}
+}
+
+func TestMapWraparound(t *testing.T) {
+	// A hash that always lands past every virtual node on the ring must
+	// still resolve to a node by wrapping back to the first one.
+	m := New(1, func(data []byte) uint32 {
+		if string(data) == "key" {
+			return ^uint32(0)
+		}
+		return 0
+	})
+	m.Add("only-node")
+
+	if got := m.Get("key"); got != "only-node" {
+		t.Fatalf("Get on wraparound = %q, want %q", got, "only-node")
+	}
+}
+
+func TestMapAddRedistributesAboutOneOverN(t *testing.T) {
+	const numKeys = 10000
+
+	before := New(0, nil)
+	before.Add("a", "b", "c")
+
+	keyOwner := make(map[string]string, numKeys)
+	for i := 0; i < numKeys; i++ {
+		k := strconv.Itoa(i)
+		keyOwner[k] = before.Get(k)
+	}
+
+	after := New(0, nil)
+	after.Add("a", "b", "c", "d")
+
+	moved := 0
+	for k, owner := range keyOwner {
+		if after.Get(k) != owner {
+			moved++
+		}
+	}
+
+	// Adding a 4th node to 3 should move roughly 1/4 of the keys; allow a
+	// generous band around that since hashing isn't perfectly uniform.
+	frac := float64(moved) / numKeys
+	if frac < 0.15 || frac > 0.35 {
+		t.Fatalf("adding a node moved %.1f%% of keys, want roughly 25%%", frac*100)
+	}
+}
+
+func TestMapGetAfterMultipleAddCalls(t *testing.T) {
+	m := New(0, nil)
+	m.Add("a")
+	m.Add("b", "c")
+
+	if got := m.Get("some-key"); got != "a" && got != "b" && got != "c" {
+		t.Fatalf("Get = %q, want one of the added nodes", got)
+	}
+}
@@ -0,0 +1,61 @@
+package sonic
+
+import (
+	"context"
+	"time"
+)
+
+// Metrics receives observability callbacks for every Push/Pop/Count/Flush*/
+// BulkPush/BulkPop call made through an Ingestable. Assign DefaultMetrics
+// before issuing any calls to plug in your own backend; by default it is a
+// no-op.
+type Metrics interface {
+	// ObserveOp is called once a single (non-bulk) operation completes, with
+	// the error it returned, if any.
+	ObserveOp(op string, dur time.Duration, err error)
+
+	// ObserveBulk is called once a BulkPush/BulkPop completes.
+	ObserveBulk(op string, attempted, failed int, dur time.Duration)
+}
+
+// TracingMetrics optionally extends Metrics for backends (such as
+// OpenTelemetry) that need to open a span before an operation starts rather
+// than only observe it afterwards. DefaultMetrics is type-asserted against
+// this interface from every context-aware ingester method.
+type TracingMetrics interface {
+	Metrics
+
+	// StartSpan is called with the ctx passed to a *Context ingester method,
+	// before the operation is dispatched. The returned context is threaded
+	// through to the underlying driver call, and end is invoked with the
+	// operation's error once it completes.
+	StartSpan(ctx context.Context, op string) (_ context.Context, end func(err error))
+}
+
+// DefaultMetrics receives every ingester operation's observability
+// callbacks. Defaults to a no-op implementation; assign it at program start,
+// before issuing any ingester calls, to plug in your own metrics backend.
+var DefaultMetrics Metrics = noopMetrics{}
+
+type noopMetrics struct{}
+
+func (noopMetrics) ObserveOp(string, time.Duration, error)      {}
+func (noopMetrics) ObserveBulk(string, int, int, time.Duration) {}
+
+// startSpan opens a span via DefaultMetrics when it implements TracingMetrics;
+// otherwise it's a no-op that returns ctx unchanged.
+func startSpan(ctx context.Context, op string) (context.Context, func(error)) {
+	tm, ok := DefaultMetrics.(TracingMetrics)
+	if !ok {
+		return ctx, func(error) {}
+	}
+	return tm.StartSpan(ctx, op)
+}
+
+// firstBulkErr returns the first recorded error in errs, or nil if errs is empty.
+func firstBulkErr(errs []IngestBulkError) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs[0].Error
+}
@@ -0,0 +1,39 @@
+// Package armonmetrics adapts sonic.Metrics to github.com/armon/go-metrics,
+// emitting sonic.ingest.<op>.latency_ms and sonic.ingest.<op>.errors for
+// every ingester operation. Kept out of the main sonic package so importing
+// it doesn't pull in the go-metrics dependency for users who don't need it.
+package armonmetrics
+
+import (
+	"time"
+
+	gometrics "github.com/armon/go-metrics"
+)
+
+// Adapter emits ingester operation metrics to a *gometrics.Metrics sink.
+type Adapter struct {
+	sink *gometrics.Metrics
+}
+
+// New returns a sonic.Metrics backed by sink. Pass gometrics.Default() to
+// use the process-wide default sink.
+func New(sink *gometrics.Metrics) *Adapter {
+	return &Adapter{sink: sink}
+}
+
+// ObserveOp implements sonic.Metrics.
+func (a *Adapter) ObserveOp(op string, dur time.Duration, err error) {
+	a.sink.AddSample([]string{"sonic", "ingest", op, "latency_ms"}, float32(dur.Milliseconds()))
+	if err != nil {
+		a.sink.IncrCounter([]string{"sonic", "ingest", op, "errors"}, 1)
+	}
+}
+
+// ObserveBulk implements sonic.Metrics.
+func (a *Adapter) ObserveBulk(op string, attempted, failed int, dur time.Duration) {
+	a.sink.AddSample([]string{"sonic", "ingest", op, "latency_ms"}, float32(dur.Milliseconds()))
+	a.sink.IncrCounter([]string{"sonic", "ingest", op, "attempted"}, float32(attempted))
+	if failed > 0 {
+		a.sink.IncrCounter([]string{"sonic", "ingest", op, "errors"}, float32(failed))
+	}
+}
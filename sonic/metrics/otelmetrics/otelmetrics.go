@@ -0,0 +1,59 @@
+// Package otelmetrics adapts sonic.TracingMetrics to OpenTelemetry, opening
+// a span for every context-aware ingester operation. Kept out of the main
+// sonic package so importing it doesn't pull in the otel dependency for
+// users who don't need it.
+package otelmetrics
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/BenSlabbert/go-sonic/sonic"
+)
+
+// Adapter implements sonic.TracingMetrics: it opens a span per operation on
+// tracer and forwards the same latency/error observations a plain
+// sonic.Metrics would receive to next.
+type Adapter struct {
+	tracer trace.Tracer
+	next   sonic.Metrics
+}
+
+// New returns a sonic.TracingMetrics that opens spans on tracer. next
+// receives the usual ObserveOp/ObserveBulk calls; pass nil to only emit spans.
+func New(tracer trace.Tracer, next sonic.Metrics) *Adapter {
+	if next == nil {
+		next = noopMetrics{}
+	}
+	return &Adapter{tracer: tracer, next: next}
+}
+
+// StartSpan implements sonic.TracingMetrics.
+func (a *Adapter) StartSpan(ctx context.Context, op string) (context.Context, func(error)) {
+	ctx, span := a.tracer.Start(ctx, "sonic.ingest."+op)
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// ObserveOp implements sonic.Metrics by delegating to next.
+func (a *Adapter) ObserveOp(op string, dur time.Duration, err error) {
+	a.next.ObserveOp(op, dur, err)
+}
+
+// ObserveBulk implements sonic.Metrics by delegating to next.
+func (a *Adapter) ObserveBulk(op string, attempted, failed int, dur time.Duration) {
+	a.next.ObserveBulk(op, attempted, failed, dur)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) ObserveOp(string, time.Duration, error)      {}
+func (noopMetrics) ObserveBulk(string, int, int, time.Duration) {}
@@ -0,0 +1,342 @@
+package sonic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/BenSlabbert/go-sonic/sonic/internal/consistenthash"
+)
+
+// defaultShardReplicas is the number of virtual nodes placed on the
+// consistent-hash ring per backend node when WithReplicas isn't used.
+const defaultShardReplicas = 150
+
+// NodeConfig describes one backend Sonic instance participating in a
+// sharded ingester.
+type NodeConfig struct {
+	// ID identifies this node on the hash ring. Defaults to "Host:Port" when empty.
+	ID string
+
+	Host     string
+	Port     int
+	Password string
+}
+
+// ShardOption configures a sharded ingester built by NewShardedIngester.
+type ShardOption func(*shardConfig)
+
+type shardConfig struct {
+	replicas int
+	hashFn   consistenthash.Hash
+}
+
+// WithReplicas overrides the number of virtual nodes placed on the ring per
+// backend node. Defaults to 150.
+func WithReplicas(replicas int) ShardOption {
+	return func(c *shardConfig) { c.replicas = replicas }
+}
+
+// WithHashFn overrides the hash function used to place nodes and keys on the
+// ring. Defaults to FNV-1a 32-bit.
+func WithHashFn(fn consistenthash.Hash) ShardOption {
+	return func(c *shardConfig) { c.hashFn = fn }
+}
+
+// shardedIngester implements Ingestable by routing each operation to one of
+// several backend Sonic instances via consistent hashing on
+// collection+"/"+bucket+"/"+object.
+type shardedIngester struct {
+	ring  *consistenthash.Map
+	nodes map[string]Ingestable
+}
+
+// NewShardedIngester connects to every node in nodes and returns an
+// Ingestable that distributes operations across them by consistent hashing.
+// Adding or removing a node later only requires rebuilding the ring; existing
+// keys keep routing to the same node except for the ~1/N share that moves.
+func NewShardedIngester(nodes []NodeConfig, opts ...ShardOption) (Ingestable, error) {
+	if len(nodes) == 0 {
+		return nil, errors.New("sonic: NewShardedIngester requires at least one node")
+	}
+
+	cfg := shardConfig{replicas: defaultShardReplicas}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	s := &shardedIngester{
+		ring:  consistenthash.New(cfg.replicas, cfg.hashFn),
+		nodes: make(map[string]Ingestable, len(nodes)),
+	}
+
+	ids := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		id := n.ID
+		if id == "" {
+			id = fmt.Sprintf("%s:%d", n.Host, n.Port)
+		}
+		if _, exists := s.nodes[id]; exists {
+			return nil, fmt.Errorf("sonic: duplicate node id %q", id)
+		}
+
+		ingester, err := NewIngester(n.Host, n.Port, n.Password)
+		if err != nil {
+			return nil, fmt.Errorf("sonic: connecting to node %q: %w", id, err)
+		}
+		s.nodes[id] = ingester
+		ids = append(ids, id)
+	}
+	s.ring.Add(ids...)
+
+	return s, nil
+}
+
+func shardKey(collection, bucket, object string) string {
+	return collection + "/" + bucket + "/" + object
+}
+
+func (s *shardedIngester) nodeFor(collection, bucket, object string) Ingestable {
+	return s.nodes[s.ring.Get(shardKey(collection, bucket, object))]
+}
+
+func (s *shardedIngester) Push(collection, bucket, object, text string) error {
+	return s.nodeFor(collection, bucket, object).Push(collection, bucket, object, text)
+}
+
+func (s *shardedIngester) PushContext(ctx context.Context, collection, bucket, object, text string) error {
+	return s.nodeFor(collection, bucket, object).PushContext(ctx, collection, bucket, object, text)
+}
+
+func (s *shardedIngester) BulkPush(collection, bucket string, parallelRoutines int, records []IngestBulkRecord) []IngestBulkError {
+	return s.bulkPushContext(context.Background(), collection, bucket, parallelRoutines, records)
+}
+
+func (s *shardedIngester) BulkPushContext(ctx context.Context, collection, bucket string, parallelRoutines int, records []IngestBulkRecord) []IngestBulkError {
+	return s.bulkPushContext(ctx, collection, bucket, parallelRoutines, records)
+}
+
+func (s *shardedIngester) bulkPushContext(ctx context.Context, collection, bucket string, parallelRoutines int, records []IngestBulkRecord) []IngestBulkError {
+	grouped := s.groupByNode(collection, bucket, records)
+
+	errs := make([]IngestBulkError, 0)
+	errMutex := &sync.Mutex{}
+	group := sync.WaitGroup{}
+	group.Add(len(grouped))
+	for id, recs := range grouped {
+		go func(id string, recs []IngestBulkRecord) {
+			defer group.Done()
+			nodeErrs := s.nodes[id].BulkPushContext(ctx, collection, bucket, parallelRoutines, recs)
+			errMutex.Lock()
+			errs = append(errs, nodeErrs...)
+			errMutex.Unlock()
+		}(id, recs)
+	}
+	group.Wait()
+	return errs
+}
+
+func (s *shardedIngester) Pop(collection, bucket, object, text string) error {
+	return s.nodeFor(collection, bucket, object).Pop(collection, bucket, object, text)
+}
+
+func (s *shardedIngester) PopContext(ctx context.Context, collection, bucket, object, text string) error {
+	return s.nodeFor(collection, bucket, object).PopContext(ctx, collection, bucket, object, text)
+}
+
+func (s *shardedIngester) BulkPop(collection, bucket string, parallelRoutines int, records []IngestBulkRecord) []IngestBulkError {
+	return s.bulkPopContext(context.Background(), collection, bucket, parallelRoutines, records)
+}
+
+func (s *shardedIngester) BulkPopContext(ctx context.Context, collection, bucket string, parallelRoutines int, records []IngestBulkRecord) []IngestBulkError {
+	return s.bulkPopContext(ctx, collection, bucket, parallelRoutines, records)
+}
+
+func (s *shardedIngester) bulkPopContext(ctx context.Context, collection, bucket string, parallelRoutines int, records []IngestBulkRecord) []IngestBulkError {
+	grouped := s.groupByNode(collection, bucket, records)
+
+	errs := make([]IngestBulkError, 0)
+	errMutex := &sync.Mutex{}
+	group := sync.WaitGroup{}
+	group.Add(len(grouped))
+	for id, recs := range grouped {
+		go func(id string, recs []IngestBulkRecord) {
+			defer group.Done()
+			nodeErrs := s.nodes[id].BulkPopContext(ctx, collection, bucket, parallelRoutines, recs)
+			errMutex.Lock()
+			errs = append(errs, nodeErrs...)
+			errMutex.Unlock()
+		}(id, recs)
+	}
+	group.Wait()
+	return errs
+}
+
+func (s *shardedIngester) groupByNode(collection, bucket string, records []IngestBulkRecord) map[string][]IngestBulkRecord {
+	grouped := make(map[string][]IngestBulkRecord)
+	for _, rec := range records {
+		id := s.ring.Get(shardKey(collection, bucket, rec.Object))
+		grouped[id] = append(grouped[id], rec)
+	}
+	return grouped
+}
+
+func (s *shardedIngester) Count(collection, bucket, object string) (int, error) {
+	return s.countContext(context.Background(), collection, bucket, object)
+}
+
+func (s *shardedIngester) CountContext(ctx context.Context, collection, bucket, object string) (int, error) {
+	return s.countContext(ctx, collection, bucket, object)
+}
+
+func (s *shardedIngester) countContext(ctx context.Context, collection, bucket, object string) (int, error) {
+	// Only a full collection/bucket/object key maps to a single shard (see
+	// shardKey/nodeFor). A bucket alone is scattered across every node just
+	// like FlushBucket's objects are, so it must broadcast and sum too; only
+	// a count scoped down to one object can be routed directly.
+	if object != "" {
+		return s.nodeFor(collection, bucket, object).CountContext(ctx, collection, bucket, object)
+	}
+	return s.broadcastCount(ctx, collection, bucket)
+}
+
+func (s *shardedIngester) broadcastCount(ctx context.Context, collection, bucket string) (int, error) {
+	type result struct {
+		count int
+		err   error
+	}
+
+	results := make(chan result, len(s.nodes))
+	for _, node := range s.nodes {
+		go func(node Ingestable) {
+			c, err := node.CountContext(ctx, collection, bucket, "")
+			results <- result{c, err}
+		}(node)
+	}
+
+	total := 0
+	var errs []error
+	for range s.nodes {
+		r := <-results
+		if r.err != nil {
+			errs = append(errs, r.err)
+			continue
+		}
+		total += r.count
+	}
+	if len(errs) > 0 {
+		return total, errors.Join(errs...)
+	}
+	return total, nil
+}
+
+func (s *shardedIngester) FlushCollection(collection string) error {
+	return s.flushCollectionContext(context.Background(), collection)
+}
+
+func (s *shardedIngester) FlushCollectionContext(ctx context.Context, collection string) error {
+	return s.flushCollectionContext(ctx, collection)
+}
+
+func (s *shardedIngester) flushCollectionContext(ctx context.Context, collection string) error {
+	return s.broadcast(func(node Ingestable) error {
+		return node.FlushCollectionContext(ctx, collection)
+	})
+}
+
+func (s *shardedIngester) FlushBucket(collection, bucket string) error {
+	return s.flushBucketContext(context.Background(), collection, bucket)
+}
+
+func (s *shardedIngester) FlushBucketContext(ctx context.Context, collection, bucket string) error {
+	return s.flushBucketContext(ctx, collection, bucket)
+}
+
+func (s *shardedIngester) flushBucketContext(ctx context.Context, collection, bucket string) error {
+	// bucket-level routing is ambiguous: any node may hold objects from this
+	// bucket, so every node must be flushed.
+	return s.broadcast(func(node Ingestable) error {
+		return node.FlushBucketContext(ctx, collection, bucket)
+	})
+}
+
+func (s *shardedIngester) FlushObject(collection, bucket, object string) error {
+	return s.nodeFor(collection, bucket, object).FlushObject(collection, bucket, object)
+}
+
+func (s *shardedIngester) FlushObjectContext(ctx context.Context, collection, bucket, object string) error {
+	return s.nodeFor(collection, bucket, object).FlushObjectContext(ctx, collection, bucket, object)
+}
+
+// broadcast runs fn against every backend node concurrently and joins any
+// resulting errors.
+func (s *shardedIngester) broadcast(fn func(Ingestable) error) error {
+	errs := make(chan error, len(s.nodes))
+	for _, node := range s.nodes {
+		go func(node Ingestable) {
+			errs <- fn(node)
+		}(node)
+	}
+
+	var all []error
+	for range s.nodes {
+		if err := <-errs; err != nil {
+			all = append(all, err)
+		}
+	}
+	if len(all) > 0 {
+		return errors.Join(all...)
+	}
+	return nil
+}
+
+// Drain stops accepting new work on every backend node and waits for them to
+// finish up to ctx's deadline, joining any *DrainIncompleteError pending
+// records across nodes into a single one.
+func (s *shardedIngester) Drain(ctx context.Context) error {
+	type result struct {
+		pending []IngestBulkRecord
+		err     error
+	}
+
+	results := make(chan result, len(s.nodes))
+	for _, node := range s.nodes {
+		go func(node Ingestable) {
+			err := node.Drain(ctx)
+			var incomplete *DrainIncompleteError
+			if errors.As(err, &incomplete) {
+				results <- result{pending: incomplete.Pending}
+				return
+			}
+			results <- result{err: err}
+		}(node)
+	}
+
+	var pending []IngestBulkRecord
+	var errs []error
+	for range s.nodes {
+		r := <-results
+		if r.err != nil {
+			errs = append(errs, r.err)
+			continue
+		}
+		pending = append(pending, r.pending...)
+	}
+
+	if len(pending) > 0 {
+		return &DrainIncompleteError{Pending: pending}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+func (s *shardedIngester) Quit() error {
+	return s.broadcast(func(node Ingestable) error { return node.Quit() })
+}
+
+func (s *shardedIngester) Ping() error {
+	return s.broadcast(func(node Ingestable) error { return node.Ping() })
+}